@@ -0,0 +1,276 @@
+package mem
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrFaultInjected is returned by MemoryBackend operations when a
+// FaultInjector decides to simulate a transient failure.
+var ErrFaultInjected = errors.New("mem: fault injected")
+
+// Operation identifies a MemoryBackend operation that a FaultInjector can
+// target independently.
+//
+// OpList only honors WithLatency: MemoryBackend.ListFn has no way to
+// report an error (it only returns a channel of names), so
+// WithErrorRate, WithFailAfter, WithCorruption and WithTruncation have no
+// effect on it. Inject on GetReader, Load or Stat instead to exercise
+// error-recovery code that scans a listing and then fetches each entry.
+type Operation int
+
+// The operations that a FaultInjector can be configured for.
+const (
+	OpTest Operation = iota
+	OpCreate
+	OpGetReader
+	OpLoad
+	OpStat
+	OpRemove
+	OpList
+)
+
+// faultConfig holds the fault-injection settings for a single Operation.
+type faultConfig struct {
+	errorRate    float64
+	failAfterN   int
+	calls        int
+	minLatency   time.Duration
+	maxLatency   time.Duration
+	corruptBytes int
+	corruptAt    []int
+	truncateTo   int
+	truncate     bool
+}
+
+// FaultInjector simulates transient backend failures, corruption and
+// latency for a MemoryBackend. It is intended for tests that need to
+// exercise the error-recovery paths of higher-level restic code (the
+// repository, archiver and checker packages) without depending on a real
+// network or filesystem backend.
+//
+// A FaultInjector is safe for concurrent use. Configure one with the
+// With* options passed to New(), which create and tune it lazily.
+type FaultInjector struct {
+	rnd *rand.Rand
+
+	m      sync.Mutex
+	config map[Operation]*faultConfig
+}
+
+// newFaultInjector returns a FaultInjector with no faults configured and a
+// deterministic RNG seeded with seed.
+func newFaultInjector(seed int64) *FaultInjector {
+	return &FaultInjector{
+		rnd:    rand.New(rand.NewSource(seed)),
+		config: make(map[Operation]*faultConfig),
+	}
+}
+
+func (fi *FaultInjector) cfg(op Operation) *faultConfig {
+	c, ok := fi.config[op]
+	if !ok {
+		c = &faultConfig{}
+		fi.config[op] = c
+	}
+	return c
+}
+
+// fault returns be's FaultInjector, creating one seeded from the current
+// time if this is the first fault-related option applied to be.
+func fault(be *MemoryBackend) *FaultInjector {
+	if be.fault == nil {
+		be.fault = newFaultInjector(time.Now().UnixNano())
+	}
+	return be.fault
+}
+
+// WithSeed fixes the RNG used to decide errors, corruption offsets and
+// latency jitter, so that a test run is reproducible. It replaces be's
+// FaultInjector, so fault-injection options passed before it are
+// discarded; pass it first.
+func WithSeed(seed int64) Option {
+	return func(be *MemoryBackend) {
+		be.fault = newFaultInjector(seed)
+	}
+}
+
+// WithErrorRate makes op fail with the given probability (0..1) each time
+// it is invoked.
+func WithErrorRate(op Operation, rate float64) Option {
+	return func(be *MemoryBackend) {
+		fault(be).cfg(op).errorRate = rate
+	}
+}
+
+// WithFailAfter makes op start failing once it has been called n times.
+// Every call from the (n+1)th onward returns ErrFaultInjected. An n of
+// zero or less turns this mode off.
+func WithFailAfter(op Operation, n int) Option {
+	return func(be *MemoryBackend) {
+		fault(be).cfg(op).failAfterN = n
+	}
+}
+
+// WithLatency makes op sleep for a random duration in [min, max) before
+// returning. If min == max the delay is fixed.
+func WithLatency(op Operation, min, max time.Duration) Option {
+	return func(be *MemoryBackend) {
+		c := fault(be).cfg(op)
+		c.minLatency = min
+		c.maxLatency = max
+	}
+}
+
+// WithCorruption makes op (GetReader or Load) flip n bytes of the returned
+// data. If offsets is non-empty, exactly those offsets (relative to the
+// start of the returned slice) are flipped; otherwise n random offsets are
+// chosen for each call.
+func WithCorruption(op Operation, n int, offsets ...int) Option {
+	return func(be *MemoryBackend) {
+		c := fault(be).cfg(op)
+		c.corruptBytes = n
+		c.corruptAt = offsets
+	}
+}
+
+// WithTruncation makes op (GetReader or Load) return at most n bytes,
+// regardless of how much data was requested.
+func WithTruncation(op Operation, n int) Option {
+	return func(be *MemoryBackend) {
+		c := fault(be).cfg(op)
+		c.truncate = true
+		c.truncateTo = n
+	}
+}
+
+// apply runs the configured latency, error and failAfter behavior for op
+// and reports an error if the caller should abort the operation.
+func (fi *FaultInjector) apply(op Operation) error {
+	if fi == nil {
+		return nil
+	}
+
+	fi.m.Lock()
+	c, ok := fi.config[op]
+	if !ok {
+		fi.m.Unlock()
+		return nil
+	}
+
+	c.calls++
+	calls := c.calls
+	rate := c.errorRate
+	failAfter := c.failAfterN
+	min, max := c.minLatency, c.maxLatency
+
+	var jitter time.Duration
+	if max > min {
+		jitter = time.Duration(fi.rnd.Int63n(int64(max - min)))
+	}
+
+	fail := false
+	if rate > 0 && fi.rnd.Float64() < rate {
+		fail = true
+	}
+	if failAfter > 0 && calls > failAfter {
+		fail = true
+	}
+	fi.m.Unlock()
+
+	if min > 0 || max > 0 {
+		time.Sleep(min + jitter)
+	}
+
+	if fail {
+		return ErrFaultInjected
+	}
+
+	return nil
+}
+
+// latency sleeps for op's configured latency, if any. Unlike apply, it
+// does not consider errorRate or failAfterN, since it is used by
+// operations (OpList) that have no way to report an error back to their
+// caller.
+func (fi *FaultInjector) latency(op Operation) {
+	if fi == nil {
+		return
+	}
+
+	fi.m.Lock()
+	c, ok := fi.config[op]
+	if !ok {
+		fi.m.Unlock()
+		return
+	}
+
+	min, max := c.minLatency, c.maxLatency
+
+	var jitter time.Duration
+	if max > min {
+		jitter = time.Duration(fi.rnd.Int63n(int64(max - min)))
+	}
+	fi.m.Unlock()
+
+	if min > 0 || max > 0 {
+		time.Sleep(min + jitter)
+	}
+}
+
+// corrupt mutates buf in place according to op's corruption settings.
+func (fi *FaultInjector) corrupt(op Operation, buf []byte) {
+	if fi == nil || len(buf) == 0 {
+		return
+	}
+
+	fi.m.Lock()
+	c, ok := fi.config[op]
+	fi.m.Unlock()
+	if !ok || (c.corruptBytes == 0 && len(c.corruptAt) == 0) {
+		return
+	}
+
+	offsets := c.corruptAt
+	if len(offsets) == 0 {
+		// pick distinct offsets so that no byte is flipped back
+		seen := make(map[int]bool)
+		fi.m.Lock()
+		for len(offsets) < c.corruptBytes && len(offsets) < len(buf) {
+			off := fi.rnd.Intn(len(buf))
+			if !seen[off] {
+				seen[off] = true
+				offsets = append(offsets, off)
+			}
+		}
+		fi.m.Unlock()
+	}
+
+	for _, off := range offsets {
+		if off < 0 || off >= len(buf) {
+			continue
+		}
+		buf[off] ^= 0xff
+	}
+}
+
+// truncate shortens buf according to op's truncation settings.
+func (fi *FaultInjector) truncate(op Operation, buf []byte) []byte {
+	if fi == nil {
+		return buf
+	}
+
+	fi.m.Lock()
+	c, ok := fi.config[op]
+	fi.m.Unlock()
+	if !ok || !c.truncate {
+		return buf
+	}
+
+	if c.truncateTo < len(buf) {
+		return buf[:c.truncateTo]
+	}
+	return buf
+}
@@ -0,0 +1,70 @@
+package mem
+
+import (
+	"testing"
+	"time"
+
+	"github.com/restic/restic/backend"
+)
+
+func TestFaultInjectorSeedDeterministic(t *testing.T) {
+	const calls = 50
+
+	run := func() []bool {
+		be := New(WithSeed(42), WithErrorRate(OpTest, 0.5))
+		saveBlob(t, be, backend.Data, "foo", []byte("x"))
+
+		results := make([]bool, calls)
+		for i := range results {
+			_, err := be.Test(backend.Data, "foo")
+			results[i] = err == nil
+		}
+		return results
+	}
+
+	a, b := run(), run()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("call %d: same seed produced different outcomes: %v vs %v", i, a, b)
+		}
+	}
+}
+
+func TestFaultInjectorFailAfter(t *testing.T) {
+	be := New(WithFailAfter(OpTest, 3))
+	saveBlob(t, be, backend.Data, "foo", []byte("x"))
+
+	for i := 0; i < 3; i++ {
+		if _, err := be.Test(backend.Data, "foo"); err != nil {
+			t.Fatalf("call %d: expected no error, got %v", i+1, err)
+		}
+	}
+
+	if _, err := be.Test(backend.Data, "foo"); err != ErrFaultInjected {
+		t.Fatalf("call 4: expected ErrFaultInjected, got %v", err)
+	}
+}
+
+func TestFaultInjectorListLatencyOnly(t *testing.T) {
+	const delay = 20 * time.Millisecond
+
+	be := New(WithLatency(OpList, delay, delay), WithErrorRate(OpList, 1))
+	saveBlob(t, be, backend.Data, "foo", []byte("x"))
+
+	start := time.Now()
+	done := make(chan struct{})
+	defer close(done)
+
+	var names []string
+	for name := range be.List(backend.Data, done) {
+		names = append(names, name)
+	}
+
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Fatalf("List returned after %v, expected at least %v of injected latency", elapsed, delay)
+	}
+
+	if len(names) != 1 || names[0] != "foo" {
+		t.Fatalf("expected List to still return [foo] despite WithErrorRate(OpList, ...), got %v", names)
+	}
+}
@@ -24,15 +24,24 @@ type MemoryBackend struct {
 	data memMap
 	m    sync.Mutex
 
+	fault *FaultInjector
+
 	backend.MockBackend
 }
 
+// Option configures a MemoryBackend returned by New().
+type Option func(*MemoryBackend)
+
 // New returns a new backend that saves all data in a map in memory.
-func New() *MemoryBackend {
+func New(opts ...Option) *MemoryBackend {
 	be := &MemoryBackend{
 		data: make(memMap),
 	}
 
+	for _, opt := range opts {
+		opt(be)
+	}
+
 	be.MockBackend.TestFn = func(t backend.Type, name string) (bool, error) {
 		return memTest(be, t, name)
 	}
@@ -91,6 +100,10 @@ func (be *MemoryBackend) insert(t backend.Type, name string, data []byte) error
 }
 
 func memTest(be *MemoryBackend, t backend.Type, name string) (bool, error) {
+	if err := be.fault.apply(OpTest); err != nil {
+		return false, err
+	}
+
 	be.m.Lock()
 	defer be.m.Unlock()
 
@@ -128,12 +141,20 @@ func (e *tempMemEntry) Finalize(t backend.Type, name string) error {
 }
 
 func memCreate(be *MemoryBackend) (backend.Blob, error) {
+	if err := be.fault.apply(OpCreate); err != nil {
+		return nil, err
+	}
+
 	blob := &tempMemEntry{be: be}
 	debug.Log("MemoryBackend.Create", "create new blob %p", blob)
 	return blob, nil
 }
 
 func memGetReader(be *MemoryBackend, t backend.Type, name string, offset, length uint) (io.ReadCloser, error) {
+	if err := be.fault.apply(OpGetReader); err != nil {
+		return nil, err
+	}
+
 	be.m.Lock()
 	defer be.m.Unlock()
 
@@ -162,10 +183,23 @@ func memGetReader(be *MemoryBackend, t backend.Type, name string, offset, length
 		buf = buf[:length]
 	}
 
+	buf = be.fault.truncate(OpGetReader, buf)
+
+	if be.fault != nil {
+		cp := make([]byte, len(buf))
+		copy(cp, buf)
+		be.fault.corrupt(OpGetReader, cp)
+		buf = cp
+	}
+
 	return backend.ReadCloser(bytes.NewReader(buf)), nil
 }
 
 func memLoad(be *MemoryBackend, h backend.Handle, p []byte, off int64) (int, error) {
+	if err := be.fault.apply(OpLoad); err != nil {
+		return 0, err
+	}
+
 	be.m.Lock()
 	defer be.m.Unlock()
 
@@ -189,8 +223,10 @@ func memLoad(be *MemoryBackend, h backend.Handle, p []byte, off int64) (int, err
 	}
 
 	buf = buf[off:]
+	buf = be.fault.truncate(OpLoad, buf)
 
 	n := copy(p, buf)
+	be.fault.corrupt(OpLoad, p[:n])
 
 	if len(p) > len(buf) {
 		return n, io.ErrUnexpectedEOF
@@ -200,6 +236,10 @@ func memLoad(be *MemoryBackend, h backend.Handle, p []byte, off int64) (int, err
 }
 
 func memStat(be *MemoryBackend, h backend.Handle) (backend.BlobInfo, error) {
+	if err := be.fault.apply(OpStat); err != nil {
+		return backend.BlobInfo{}, err
+	}
+
 	be.m.Lock()
 	defer be.m.Unlock()
 
@@ -222,6 +262,10 @@ func memStat(be *MemoryBackend, h backend.Handle) (backend.BlobInfo, error) {
 }
 
 func memRemove(be *MemoryBackend, t backend.Type, name string) error {
+	if err := be.fault.apply(OpRemove); err != nil {
+		return err
+	}
+
 	be.m.Lock()
 	defer be.m.Unlock()
 
@@ -237,6 +281,8 @@ func memRemove(be *MemoryBackend, t backend.Type, name string) error {
 }
 
 func memList(be *MemoryBackend, t backend.Type, done <-chan struct{}) <-chan string {
+	be.fault.latency(OpList)
+
 	be.m.Lock()
 	defer be.m.Unlock()
 
@@ -0,0 +1,240 @@
+package mem
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/restic/restic/backend"
+)
+
+// snapshotMagic identifies the binary format written by Snapshot and read
+// back by Restore.
+const snapshotMagic = "resticmem"
+
+// snapshotVersion is incremented whenever the on-disk layout written by
+// Snapshot changes in an incompatible way.
+const snapshotVersion = 1
+
+// Sanity bounds applied by Restore so that a truncated or corrupted
+// snapshot fails with a decode error instead of attempting a huge or
+// negative allocation.
+const (
+	maxSnapshotEntries = 1 << 24 // 16M entries
+	maxSnapshotName    = 1 << 16 // 64KiB
+	maxSnapshotBlob    = 1 << 32 // 4GiB
+)
+
+// Snapshot serializes the complete contents of be (every entry's Type,
+// Name and data) to a single versioned, self-contained byte slice. The
+// result can be written to disk and later handed to Restore, possibly by
+// a different MemoryBackend, to reproduce the exact repository state.
+// This is useful for seeding tests from a golden file or for attaching a
+// repro case to a bug report.
+//
+// Entries are written sorted by (Type, Name) rather than in map order, so
+// that Snapshot is byte-for-byte reproducible for identical backend state
+// and golden files can be diffed directly.
+func (be *MemoryBackend) Snapshot() ([]byte, error) {
+	be.m.Lock()
+	defer be.m.Unlock()
+
+	entries := make([]entry, 0, len(be.data))
+	for e := range be.data {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Type != entries[j].Type {
+			return entries[i].Type < entries[j].Type
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	var buf bytes.Buffer
+	buf.WriteString(snapshotMagic)
+	buf.WriteByte(snapshotVersion)
+
+	if err := binary.Write(&buf, binary.LittleEndian, uint32(len(entries))); err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		typ := []byte(e.Type)
+		if err := binary.Write(&buf, binary.LittleEndian, uint32(len(typ))); err != nil {
+			return nil, err
+		}
+		buf.Write(typ)
+
+		name := []byte(e.Name)
+		if err := binary.Write(&buf, binary.LittleEndian, uint32(len(name))); err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+
+		data := be.data[e]
+		if err := binary.Write(&buf, binary.LittleEndian, uint64(len(data))); err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Restore replaces be's contents with the state previously captured by
+// Snapshot. Existing data in be is discarded.
+func (be *MemoryBackend) Restore(r io.Reader) error {
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return err
+	}
+	if string(magic) != snapshotMagic {
+		return errors.New("mem: not a MemoryBackend snapshot")
+	}
+
+	var version uint8
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return err
+	}
+	if version != snapshotVersion {
+		return fmt.Errorf("mem: unsupported snapshot version %d", version)
+	}
+
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return err
+	}
+	if count > maxSnapshotEntries {
+		return fmt.Errorf("mem: snapshot claims %d entries, refusing to decode", count)
+	}
+
+	data := make(memMap, count)
+
+	for i := uint32(0); i < count; i++ {
+		var typLen uint32
+		if err := binary.Read(r, binary.LittleEndian, &typLen); err != nil {
+			return err
+		}
+		if typLen > maxSnapshotName {
+			return fmt.Errorf("mem: snapshot entry type length %d exceeds limit", typLen)
+		}
+		typ := make([]byte, typLen)
+		if _, err := io.ReadFull(r, typ); err != nil {
+			return err
+		}
+
+		var nameLen uint32
+		if err := binary.Read(r, binary.LittleEndian, &nameLen); err != nil {
+			return err
+		}
+		if nameLen > maxSnapshotName {
+			return fmt.Errorf("mem: snapshot entry name length %d exceeds limit", nameLen)
+		}
+		name := make([]byte, nameLen)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return err
+		}
+
+		var dataLen uint64
+		if err := binary.Read(r, binary.LittleEndian, &dataLen); err != nil {
+			return err
+		}
+		if dataLen > maxSnapshotBlob {
+			return fmt.Errorf("mem: snapshot entry data length %d exceeds limit", dataLen)
+		}
+		blob := make([]byte, dataLen)
+		if _, err := io.ReadFull(r, blob); err != nil {
+			return err
+		}
+
+		data[entry{backend.Type(typ), string(name)}] = blob
+	}
+
+	be.m.Lock()
+	defer be.m.Unlock()
+	be.data = data
+
+	return nil
+}
+
+// SaveTo writes the result of Snapshot to the file at path.
+func (be *MemoryBackend) SaveTo(path string) error {
+	data, err := be.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// LoadFrom reads a file previously written by SaveTo and passes it to
+// Restore.
+func (be *MemoryBackend) LoadFrom(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return be.Restore(bytes.NewReader(data))
+}
+
+// copyTypes lists every backend.Type that CopyBackend walks. It mirrors
+// the set of object types restic stores in a repository.
+var copyTypes = []backend.Type{
+	backend.Data,
+	backend.Key,
+	backend.Lock,
+	backend.Snapshot,
+	backend.Index,
+	backend.Config,
+}
+
+// CopyBackend copies every entry from src to dst, across all object
+// types. src and dst may be any backend.Backend implementation, for
+// example a MemoryBackend populated via LoadFrom and a real on-disk or
+// remote backend under test, which makes it possible to round-trip a
+// captured repository state between the two.
+func CopyBackend(src, dst backend.Backend) error {
+	for _, t := range copyTypes {
+		done := make(chan struct{})
+
+		for name := range src.List(t, done) {
+			if err := copyEntry(src, dst, t, name); err != nil {
+				close(done)
+				return err
+			}
+		}
+
+		close(done)
+	}
+
+	return nil
+}
+
+func copyEntry(src, dst backend.Backend, t backend.Type, name string) error {
+	rd, err := src.GetReader(t, name, 0, 0)
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+
+	buf, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+
+	blob, err := dst.Create()
+	if err != nil {
+		return err
+	}
+
+	if _, err := blob.Write(buf); err != nil {
+		return err
+	}
+
+	return blob.Finalize(t, name)
+}
@@ -0,0 +1,104 @@
+package mem
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/restic/restic/backend"
+)
+
+func saveBlob(t *testing.T, be *MemoryBackend, typ backend.Type, name string, content []byte) {
+	blob, err := be.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blob.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := blob.Finalize(typ, name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotRestoreRoundTrip(t *testing.T) {
+	be := New()
+
+	saveBlob(t, be, backend.Data, "foo", []byte("foo data"))
+	saveBlob(t, be, backend.Snapshot, "bar", []byte("bar data"))
+	saveBlob(t, be, backend.Config, "", []byte("config data"))
+
+	snap, err := be.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := New()
+	if err := restored.Restore(bytes.NewReader(snap)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(restored.data) != len(be.data) {
+		t.Fatalf("expected %d entries, got %d", len(be.data), len(restored.data))
+	}
+
+	for e, content := range be.data {
+		got, ok := restored.data[e]
+		if !ok {
+			t.Fatalf("missing entry %v after restore", e)
+		}
+		if !bytes.Equal(got, content) {
+			t.Fatalf("entry %v: expected %q, got %q", e, content, got)
+		}
+	}
+}
+
+func TestSnapshotByteStable(t *testing.T) {
+	be := New()
+
+	saveBlob(t, be, backend.Data, "foo", []byte("foo data"))
+	saveBlob(t, be, backend.Snapshot, "bar", []byte("bar data"))
+	saveBlob(t, be, backend.Index, "baz", []byte("index data"))
+	saveBlob(t, be, backend.Config, "", []byte("config data"))
+
+	first, err := be.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		again, err := be.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(first, again) {
+			t.Fatalf("Snapshot produced different bytes on call %d for unchanged state", i)
+		}
+	}
+}
+
+func TestCopyBackendRoundTrip(t *testing.T) {
+	src := New()
+
+	saveBlob(t, src, backend.Data, "hello-id", []byte("hello"))
+	saveBlob(t, src, backend.Index, "idx-id", []byte("index data"))
+	saveBlob(t, src, backend.Config, "", []byte("config data"))
+
+	dst := New()
+	if err := CopyBackend(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dst.data) != len(src.data) {
+		t.Fatalf("expected %d entries in dst, got %d", len(src.data), len(dst.data))
+	}
+
+	for e, content := range src.data {
+		got, ok := dst.data[e]
+		if !ok {
+			t.Fatalf("missing entry %v after copy", e)
+		}
+		if !bytes.Equal(got, content) {
+			t.Fatalf("entry %v: expected %q, got %q", e, content, got)
+		}
+	}
+}